@@ -0,0 +1,263 @@
+// Package cache implements a content-addressed store of converted/resized
+// image blobs, keyed on the inputs that determine their bytes, so repeated
+// runs over an unchanged tree skip redundant conversions.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key describes every input that determines a converted output's bytes.
+// Two conversions with equal Keys are expected to produce identical output.
+type Key struct {
+	InputDigest string   // sha256 of the source file, hex-encoded
+	ToolVersion string   // cache schema/tool version, bumped on incompatible changes
+	CwebpArgs   []string // extra cwebp arguments, only set for the cwebp engine
+	Width       int
+	Height      int
+	Engine      string
+	Filter      string
+	Quality     float64
+}
+
+// Digest returns the content address for Key: a sha256 hex digest over a
+// canonical encoding of its fields.
+func (k Key) Digest() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "input=%s\nversion=%s\nargs=%s\nwidth=%d\nheight=%d\nengine=%s\nfilter=%s\nquality=%g\n",
+		k.InputDigest, k.ToolVersion, strings.Join(k.CwebpArgs, "\x00"), k.Width, k.Height, k.Engine, k.Filter, k.Quality)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HashFile returns the sha256 hex digest of the file at path, computed via
+// a single streamed read.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.Discard, io.TeeReader(f, h)); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Dir returns $XDG_CACHE_HOME/cmd-img/blobs, falling back to
+// ~/.cache/cmd-img/blobs per the XDG base directory spec.
+func Dir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "cmd-img", "blobs"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cache", "cmd-img", "blobs"), nil
+}
+
+// Cache is a content-addressed store of converted/resized output blobs,
+// sharded two hex characters deep to keep any one directory small.
+type Cache struct {
+	Dir string
+}
+
+// Open returns a Cache rooted at dir, creating it if necessary. An empty
+// dir falls back to the default cache directory.
+func Open(dir string) (*Cache, error) {
+	if dir == "" {
+		d, err := Dir()
+		if err != nil {
+			return nil, err
+		}
+		dir = d
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache dir: %w", err)
+	}
+	return &Cache{Dir: dir}, nil
+}
+
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.Dir, digest[:2], digest)
+}
+
+// Lookup reports whether digest is cached. On a hit it also touches the
+// blob's mtime, which GC uses as an LRU-by-access-time proxy since Go has
+// no portable way to read atime.
+func (c *Cache) Lookup(digest string) (path string, ok bool) {
+	p := c.blobPath(digest)
+	if _, err := os.Stat(p); err != nil {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return p, true
+}
+
+// Store moves the file at tmpPath into the cache under digest and returns
+// its blob path. It falls back to copy+remove when tmpPath is on a
+// different filesystem than the cache dir.
+func (c *Cache) Store(digest, tmpPath string) (string, error) {
+	p := c.blobPath(digest)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", fmt.Errorf("creating cache shard dir: %w", err)
+	}
+	if err := os.Rename(tmpPath, p); err == nil {
+		return p, nil
+	}
+	if err := copyFile(tmpPath, p); err != nil {
+		return "", fmt.Errorf("copying artifact into cache: %w", err)
+	}
+	_ = os.Remove(tmpPath)
+	return p, nil
+}
+
+// LinkOrCopy materializes the cached blob at src as dst, preferring a hard
+// link and falling back to a copy (e.g. across filesystems). dst is never
+// truncated in place: since dst may already be hard-linked to src from a
+// previous run (the common case when reconverting to the same output
+// path), writing into it directly would zero out the shared inode and
+// permanently corrupt the cache blob. Instead any existing dst is removed
+// before linking, and the copy fallback writes through a temp file that is
+// renamed over dst.
+func LinkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing existing %s: %w", dst, err)
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-cmd-img-*")
+	if err != nil {
+		return fmt.Errorf("creating temp file for %s: %w", dst, err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	if err := copyFile(src, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming into place %s: %w", dst, err)
+	}
+	return nil
+}
+
+// GC deletes cached blobs, least-recently-touched first, until the cache is
+// at or under maxSize bytes. It returns how many blobs were removed and how
+// many bytes were freed.
+func (c *Cache) GC(maxSize int64) (removed int, freed int64, err error) {
+	type blob struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var blobs []blob
+	var total int64
+
+	walkErr := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, ierr := d.Info()
+		if ierr != nil {
+			return ierr
+		}
+		blobs = append(blobs, blob{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if walkErr != nil {
+		return 0, 0, fmt.Errorf("walking cache dir: %w", walkErr)
+	}
+	if total <= maxSize {
+		return 0, 0, nil
+	}
+
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].modTime.Before(blobs[j].modTime) })
+
+	for _, b := range blobs {
+		if total <= maxSize {
+			break
+		}
+		if rmErr := os.Remove(b.path); rmErr != nil {
+			return removed, freed, fmt.Errorf("removing %s: %w", b.path, rmErr)
+		}
+		total -= b.size
+		freed += b.size
+		removed++
+	}
+	return removed, freed, nil
+}
+
+// ParseSize parses a human byte size such as "500M" or "2G", or a plain
+// byte count, into bytes. Recognized suffixes are K, M, G and T (1024-based).
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	mult := int64(1)
+	numPart := s
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		mult = 1 << 10
+	case 'm', 'M':
+		mult = 1 << 20
+	case 'g', 'G':
+		mult = 1 << 30
+	case 't', 'T':
+		mult = 1 << 40
+	}
+	if mult != 1 {
+		numPart = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseFloat(numPart, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1024", 1024},
+		{"500K", 500 << 10},
+		{"2G", 2 << 30},
+		{"1.5M", int64(1.5 * (1 << 20))},
+	}
+	for _, c := range cases {
+		got, err := ParseSize(c.in)
+		if err != nil {
+			t.Errorf("ParseSize(%q) error: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", c.in, got, c.want)
+		}
+	}
+
+	if _, err := ParseSize(""); err == nil {
+		t.Error("ParseSize(\"\") should error")
+	}
+	if _, err := ParseSize("abc"); err == nil {
+		t.Error(`ParseSize("abc") should error`)
+	}
+}
+
+func TestKeyDigestDeterministic(t *testing.T) {
+	k := Key{InputDigest: "abc", ToolVersion: "1", Width: 100, Height: 200, Engine: "native", Filter: "lanczos", Quality: 80}
+	if k.Digest() != k.Digest() {
+		t.Fatal("Digest() is not deterministic")
+	}
+
+	other := k
+	other.Width = 101
+	if k.Digest() == other.Digest() {
+		t.Fatal("Digest() did not change when a field changed")
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got != want {
+		t.Fatalf("HashFile = %s, want %s", got, want)
+	}
+}
+
+// TestLinkOrCopyReconvertDoesNotTruncate guards against the bug where
+// converting to the same output path twice left dst still hard-linked to the
+// cache blob, so writing into dst in place zeroed out both files.
+func TestLinkOrCopyReconvertDoesNotTruncate(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "blob")
+	dst := filepath.Join(dir, "out.webp")
+	if err := os.WriteFile(src, []byte("cached bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LinkOrCopy(src, dst); err != nil {
+		t.Fatalf("first LinkOrCopy: %v", err)
+	}
+	// Re-running against the same destination is the common "reconvert"
+	// case: dst already exists and is hard-linked to src.
+	if err := LinkOrCopy(src, dst); err != nil {
+		t.Fatalf("second LinkOrCopy: %v", err)
+	}
+
+	gotSrc, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotDst, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotSrc) != "cached bytes" {
+		t.Fatalf("cache blob corrupted: %q", gotSrc)
+	}
+	if string(gotDst) != "cached bytes" {
+		t.Fatalf("dst corrupted: %q", gotDst)
+	}
+}
+
+func TestCacheStoreAndLookup(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := filepath.Join(t.TempDir(), "artifact")
+	if err := os.WriteFile(tmp, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	digest := "deadbeef"
+	path, err := c.Store(digest, tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("stored blob missing: %v", err)
+	}
+
+	got, ok := c.Lookup(digest)
+	if !ok {
+		t.Fatal("Lookup should find the stored digest")
+	}
+	if got != path {
+		t.Fatalf("Lookup path = %s, want %s", got, path)
+	}
+
+	if _, ok := c.Lookup("notfound"); ok {
+		t.Fatal("Lookup should not find an unknown digest")
+	}
+}
+
+func TestCacheGCEvictsLeastRecentlyUsedFirst(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(digest string, size int, age time.Duration) {
+		p := c.blobPath(digest)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mt := time.Now().Add(-age)
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("aa000000000000000000000000000000000000000000000000000000000000", 100, 3*time.Hour)
+	write("bb000000000000000000000000000000000000000000000000000000000000", 100, 2*time.Hour)
+	write("cc000000000000000000000000000000000000000000000000000000000000", 100, 1*time.Hour)
+
+	removed, freed, err := c.GC(150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 || freed != 200 {
+		t.Fatalf("GC removed=%d freed=%d, want removed=2 freed=200", removed, freed)
+	}
+
+	if _, ok := c.Lookup("cc000000000000000000000000000000000000000000000000000000000000"); !ok {
+		t.Fatal("most recently touched blob should survive GC")
+	}
+	if _, ok := c.Lookup("aa000000000000000000000000000000000000000000000000000000000000"); ok {
+		t.Fatal("oldest blob should have been evicted")
+	}
+}
@@ -0,0 +1,60 @@
+package cmdimg
+
+import (
+	"fmt"
+
+	"github.com/jlrickert/cmd-img/cache"
+	"github.com/spf13/cobra"
+)
+
+// cacheSchemaVersion is bumped whenever a change to the conversion pipeline
+// would make previously cached blobs invalid for the same inputs.
+const cacheSchemaVersion = "1"
+
+// newCacheCmd builds the `cache` command group for inspecting and
+// maintaining the content-addressed output cache. Dry-run and trace come
+// from r's Shell, so the global -n/-x flags apply here too.
+func newCacheCmd(r *Runner) *cobra.Command {
+	var cacheDir string
+
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and maintain the content-addressed output cache",
+	}
+	cmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "cache directory (default: $XDG_CACHE_HOME/cmd-img/blobs)")
+
+	var maxSize string
+	gcCmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Evict least-recently-used cache entries until the cache is at or under --max-size",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			max, err := cache.ParseSize(maxSize)
+			if err != nil {
+				return err
+			}
+			c, err := cache.Open(cacheDir)
+			if err != nil {
+				return err
+			}
+
+			var removed int
+			var freed int64
+			desc := fmt.Sprintf("cache gc --max-size=%s", maxSize)
+			if err := r.Shell.Do(desc, func() error {
+				removed, freed, err = c.GC(max)
+				return err
+			}); err != nil {
+				return err
+			}
+			if !r.Shell.DryRun {
+				fmt.Fprintf(cmd.OutOrStdout(), "Removed %d cache entries, freed %d bytes\n", removed, freed)
+			}
+			return nil
+		},
+	}
+	gcCmd.Flags().StringVar(&maxSize, "max-size", "1G", "maximum cache size to retain (e.g. 500M, 2G)")
+	cmd.AddCommand(gcCmd)
+
+	return cmd
+}
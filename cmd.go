@@ -9,12 +9,53 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 
+	"github.com/jlrickert/cmd-img/cache"
+	"github.com/jlrickert/cmd-img/internal/walker"
+	"github.com/jlrickert/cmd-img/pipeline"
 	"github.com/spf13/cobra"
 )
 
+// convertOptions carries the flags shared by convert, convert-all and
+// resize that control how images are turned into WebP.
+type convertOptions struct {
+	engine   pipeline.Engine
+	filter   pipeline.Filter
+	quality  float64
+	noCache  bool
+	cacheDir string
+}
+
+func defaultEngine() pipeline.Engine {
+	if pipeline.Available() {
+		return pipeline.EngineNative
+	}
+	return pipeline.EngineCwebp
+}
+
+// convertOptionsFromFlags reads the --engine/--filter/--quality persistent
+// flags shared by convert, convert-all and resize.
+func convertOptionsFromFlags(cmd *cobra.Command) (convertOptions, error) {
+	engineStr, _ := cmd.Flags().GetString("engine")
+	filterStr, _ := cmd.Flags().GetString("filter")
+	quality, _ := cmd.Flags().GetFloat64("quality")
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	cacheDir, _ := cmd.Flags().GetString("cache-dir")
+
+	engine, err := pipeline.ParseEngine(engineStr)
+	if err != nil {
+		return convertOptions{}, err
+	}
+	filter, err := pipeline.ParseFilter(filterStr)
+	if err != nil {
+		return convertOptions{}, err
+	}
+	return convertOptions{engine: engine, filter: filter, quality: quality, noCache: noCache, cacheDir: cacheDir}, nil
+}
+
 // Run now uses cobra for command handling and completions.
 // It expects args similar to os.Args (program name at index 0).
 // Callers typically pass os.Args as before.
@@ -24,6 +65,9 @@ func Run(ctx context.Context, args []string) error {
 		args = args[1:]
 	}
 
+	var dryRun, trace bool
+	runner := &Runner{}
+
 	root := &cobra.Command{
 		Use:   "img",
 		Short: "Image helper: convert/resize to WebP (ports legacy img script)",
@@ -31,14 +75,32 @@ func Run(ctx context.Context, args []string) error {
 
 Subcommands:
   convert <file>              Convert a single image to <basename>.webp
-  convert-all                 Convert all jpg/png files in cwd to webp (requires fd)
+  convert-all                 Convert all matching image files in cwd to webp (see --recursive, --include, --exclude)
   resize --file <file> --width <width> --height <height> [cwebp args...]
 							 Resize an image and write <basename>-w{width}-h{height}.{ext}
   normalize <file> [file... ] Normalize one or more filenames: lowercase, spaces -> -, collapse repeated -
   normalize-all               Normalize all files in cwd (non-recursive)
+  rename <path> [path...]     Batch rename using --pattern/--replacement and predefined transforms
+  undo [--last | --file ..]   Undo a previous rename batch
+  cache gc --max-size <size>  Evict least-recently-used entries from the output cache
+  pack <dir>                  Convert a directory tree of images into a .cbz/.zip/.tar.zst archive
+  unpack <archive>            Extract an archive written by pack, normalizing filenames
   (no subcommand)             Any args are forwarded to cwebp
+
+Global flags:
+  --engine native|cwebp       Conversion engine (default: native if available, else cwebp)
+  --filter catmullrom|bilinear Resize filter used by the native engine
+  --quality N                 WebP quality 0-100 used by the native engine
+  --no-cache                  Skip the output cache and always reconvert
+  --cache-dir PATH            Output cache directory (default: $XDG_CACHE_HOME/cmd-img/blobs)
+  -n, --dry-run               Print commands and file mutations but perform nothing
+  -x, --trace                 Echo every command and filesystem action to stderr before executing
 `,
 		Args: cobra.ArbitraryArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			runner.Shell = NewShell(cmd.OutOrStdout(), cmd.ErrOrStderr(), dryRun, trace)
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
 			// If no args: show help/usage
 			if len(cmdArgs) == 0 {
@@ -48,9 +110,11 @@ Subcommands:
 			if _, err := exec.LookPath("cwebp"); err != nil {
 				return fmt.Errorf("cwebp is required but not found in PATH")
 			}
-			return runCmd(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), "cwebp", cmdArgs...)
+			return runner.Shell.Run(cmd.Context(), "cwebp", cmdArgs...)
 		},
 	}
+	root.PersistentFlags().BoolVarP(&dryRun, "dry-run", "n", false, "print commands and file mutations but perform nothing")
+	root.PersistentFlags().BoolVarP(&trace, "trace", "x", false, "echo every command and filesystem action to stderr before executing")
 
 	// convert
 	convertCmd := &cobra.Command{
@@ -58,33 +122,55 @@ Subcommands:
 		Short: "Convert a single image to <basename>.webp",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
-			if _, err := exec.LookPath("cwebp"); err != nil {
-				return fmt.Errorf("cwebp is required but not found in PATH")
+			opts, err := convertOptionsFromFlags(cmd)
+			if err != nil {
+				return err
 			}
-			return imgConvert(
-				cmd.Context(),
-				cmd.OutOrStdout(),
-				cmd.ErrOrStderr(),
-				cmdArgs...,
-			)
+			if opts.engine == pipeline.EngineCwebp {
+				if _, err := exec.LookPath("cwebp"); err != nil {
+					return fmt.Errorf("cwebp is required but not found in PATH")
+				}
+			}
+			return imgConvert(cmd.Context(), runner, opts, cmdArgs...)
 		},
 	}
 
 	// convert-all
+	var (
+		jobs           int
+		recursive      bool
+		include        []string
+		exclude        []string
+		followSymlinks bool
+	)
 	convertAllCmd := &cobra.Command{
 		Use:   "convert-all",
-		Short: "Convert all jpg/png files in cwd to webp (requires fd)",
+		Short: "Convert all matching image files in cwd to webp",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
-			if _, err := exec.LookPath("cwebp"); err != nil {
-				return fmt.Errorf("cwebp is required but not found in PATH")
+			opts, err := convertOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if opts.engine == pipeline.EngineCwebp {
+				if _, err := exec.LookPath("cwebp"); err != nil {
+					return fmt.Errorf("cwebp is required but not found in PATH")
+				}
 			}
-			if _, err := exec.LookPath("fd"); err != nil {
-				return fmt.Errorf("convert-all requires 'fd' in PATH")
+			wOpts := walker.Options{
+				Recursive:      recursive,
+				Include:        include,
+				Exclude:        exclude,
+				FollowSymlinks: followSymlinks,
 			}
-			return imgConvertAll(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+			return imgConvertAll(cmd.Context(), runner, opts, wOpts, jobs)
 		},
 	}
+	convertAllCmd.Flags().IntVar(&jobs, "jobs", runtime.NumCPU(), "number of files to convert concurrently")
+	convertAllCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "recurse into subdirectories")
+	convertAllCmd.Flags().StringSliceVar(&include, "include", []string{"jpg", "jpeg", "png"}, "file extensions to convert (comma-separated)")
+	convertAllCmd.Flags().StringArrayVar(&exclude, "exclude", nil, "glob pattern to exclude, matched against basenames (repeatable)")
+	convertAllCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "follow symlinked files and directories")
 
 	// resize - required parameters moved to flags: --file --width --height
 	resizeCmd := &cobra.Command{
@@ -92,20 +178,27 @@ Subcommands:
 		Short: "Resize an image and write <basename>-w{width}-h{height}.{ext}",
 		Args:  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
-			if _, err := exec.LookPath("cwebp"); err != nil {
-				return fmt.Errorf("cwebp is required but not found in PATH")
+			opts, err := convertOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if opts.engine == pipeline.EngineCwebp {
+				if _, err := exec.LookPath("cwebp"); err != nil {
+					return fmt.Errorf("cwebp is required but not found in PATH")
+				}
 			}
 			file, _ := cmd.Flags().GetString("file")
 			width, _ := cmd.Flags().GetInt("width")
 			height, _ := cmd.Flags().GetInt("height")
 
 			// positional args after flags are passed as extra cwebp args
+			// (only used by the cwebp engine)
 			extra := []string{}
 			if len(cmdArgs) > 0 {
 				extra = cmdArgs
 			}
 
-			return imgResize(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), file, strconv.Itoa(width), strconv.Itoa(height), extra...)
+			return imgResize(cmd.Context(), runner, file, strconv.Itoa(width), strconv.Itoa(height), opts, extra...)
 		},
 	}
 	resizeCmd.Flags().String("file", "", "input file to resize")
@@ -124,7 +217,7 @@ Subcommands:
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
 			del, _ := cmd.Flags().GetBool("delete")
-			return imgNormalizeMany(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), cmdArgs, del)
+			return imgNormalizeMany(cmd.Context(), runner, cmdArgs, del)
 		},
 	}
 	normalizeCmd.Flags().Bool("delete", false, "remove original files after creating normalized copy")
@@ -136,7 +229,7 @@ Subcommands:
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
 			del, _ := cmd.Flags().GetBool("delete")
-			return imgNormalizeAll(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), del)
+			return imgNormalizeAll(cmd.Context(), runner, del)
 		},
 	}
 	normalizeAllCmd.Flags().Bool("delete", false, "remove original files after creating normalized copy")
@@ -167,7 +260,13 @@ Subcommands:
 		},
 	}
 
-	root.AddCommand(convertCmd, convertAllCmd, resizeCmd, normalizeCmd, normalizeAllCmd, completionCmd)
+	root.PersistentFlags().String("engine", string(defaultEngine()), "conversion engine: native (in-process) or cwebp (shell out)")
+	root.PersistentFlags().String("filter", string(pipeline.FilterCatmullRom), "resize filter for the native engine: catmullrom or bilinear")
+	root.PersistentFlags().Float64("quality", 75, "WebP quality (0-100) used by the native engine")
+	root.PersistentFlags().Bool("no-cache", false, "skip the output cache and always reconvert")
+	root.PersistentFlags().String("cache-dir", "", "output cache directory (default: $XDG_CACHE_HOME/cmd-img/blobs)")
+
+	root.AddCommand(convertCmd, convertAllCmd, resizeCmd, normalizeCmd, normalizeAllCmd, newRenameCmd(runner), newUndoCmd(runner), newCacheCmd(runner), newPackCmd(runner), newUnpackCmd(runner), completionCmd)
 
 	// Set args for cobra and execute with provided context
 	root.SetArgs(args)
@@ -178,7 +277,7 @@ Subcommands:
 	return nil
 }
 
-func imgConvert(ctx context.Context, out, errOut io.Writer, files ...string) error {
+func imgConvert(ctx context.Context, r *Runner, opts convertOptions, files ...string) error {
 	for _, file := range files {
 		// Check file exists
 		if fi, err := os.Stat(file); err != nil || fi.IsDir() {
@@ -188,29 +287,55 @@ func imgConvert(ctx context.Context, out, errOut io.Writer, files ...string) err
 		base := file[:len(file)-len(filepath.Ext(file))]
 		outPath := fmt.Sprintf("%s.%s", base, ext)
 
-		if err := runCmd(ctx, out, errOut, "cwebp", file, "-o", outPath); err != nil {
-			return fmt.Errorf("cwebp conversion failed: %w", err)
+		if err := convertWithCache(ctx, r, opts, file, outPath, 0, 0, nil); err != nil {
+			return err
+		}
+		if !r.Shell.DryRun {
+			fmt.Fprintf(r.Shell.Stdout, "Successfully converted '%s' to '%s'\n", file, outPath)
 		}
-		fmt.Fprintf(out, "Successfully converted '%s' to '%s'\n", file, outPath)
 	}
 	return nil
 }
 
-func imgConvertAll(ctx context.Context, out, errOut io.Writer) error {
-	// Use fd to convert jpg and png using fd's replacement patterns.
-	cmdStrJpg := `fd . -e jpg --no-ignore -x cwebp "{}" -o "{.}.webp"`
-	cmdStrPng := `fd . -e png --no-ignore -x cwebp "{}" -o "{.}.webp"`
-
-	if err := runShell(ctx, out, errOut, cmdStrJpg); err != nil {
-		return fmt.Errorf("converting jpg files failed: %w", err)
+// imgConvertAll converts every file under cwd matching wOpts (jpg/png by
+// default) to webp, walking the tree in process and converting matches
+// across a bounded worker pool instead of shelling out to `fd`. Progress
+// (files done/total, bytes saved) streams to stderr as each file completes.
+func imgConvertAll(ctx context.Context, r *Runner, opts convertOptions, wOpts walker.Options, jobs int) error {
+	files, err := walker.Collect(".", wOpts)
+	if err != nil {
+		return fmt.Errorf("walking current directory: %w", err)
 	}
-	if err := runShell(ctx, out, errOut, cmdStrPng); err != nil {
-		return fmt.Errorf("converting png files failed: %w", err)
+	if len(files) == 0 {
+		fmt.Fprintln(r.Shell.Stdout, "No matching files found")
+		return nil
 	}
-	return nil
+
+	return walker.Run(files, jobs, r.Shell.Stderr, func(file string) (int64, error) {
+		srcInfo, statErr := os.Stat(file)
+		if statErr != nil {
+			return 0, statErr
+		}
+
+		base := file[:len(file)-len(filepath.Ext(file))]
+		outPath := fmt.Sprintf("%s.webp", base)
+		if err := convertWithCache(ctx, r, opts, file, outPath, 0, 0, nil); err != nil {
+			return 0, err
+		}
+		if r.Shell.DryRun {
+			return 0, nil
+		}
+
+		dstInfo, statErr := os.Stat(outPath)
+		if statErr != nil {
+			// Conversion succeeded even if we can't stat the result.
+			return 0, nil
+		}
+		return srcInfo.Size() - dstInfo.Size(), nil
+	})
 }
 
-func imgResize(ctx context.Context, out, errOut io.Writer, file, wStr, hStr string, extraArgs ...string) error {
+func imgResize(ctx context.Context, r *Runner, file, wStr, hStr string, opts convertOptions, extraArgs ...string) error {
 	// Validate file
 	if fi, err := os.Stat(file); err != nil || fi.IsDir() {
 		return fmt.Errorf("file does not exist or is a directory: %s", file)
@@ -228,6 +353,22 @@ func imgResize(ctx context.Context, out, errOut io.Writer, file, wStr, hStr stri
 		return errors.New("width and height must be non-negative")
 	}
 
+	ext := filepath.Ext(file)
+	if ext == "" {
+		ext = ".webp"
+	}
+	ext = ext[1:] // remove leading dot
+	base := file[:len(file)-len(filepath.Ext(file))]
+
+	// Under --dry-run, skip the temp file/conversion/copy dance entirely and
+	// just report the planned output; the exact final name may differ when
+	// width or height is auto-detected from the source image.
+	if r.Shell.DryRun {
+		outPath := fmt.Sprintf("%s-w%d-h%d.%s", base, w, h, ext)
+		fmt.Fprintf(r.Shell.Stdout, "resize %s -> %s\n", file, outPath)
+		return nil
+	}
+
 	// create a temp output file
 	tmpFile, err := os.CreateTemp("", "img_resize_*")
 	if err != nil {
@@ -238,24 +379,18 @@ func imgResize(ctx context.Context, out, errOut io.Writer, file, wStr, hStr stri
 	// Ensure removal of tmp file on exit
 	defer os.Remove(tmpPath)
 
-	// Build cwebp args: -resize w h <in> -o <tmp>
-	args := []string{"-resize", strconv.Itoa(w), strconv.Itoa(h), file, "-o", tmpPath}
-	if len(extraArgs) > 0 {
-		args = append(args, extraArgs...)
-	}
-
-	if err := runCmd(ctx, out, errOut, "cwebp", args...); err != nil {
-		return fmt.Errorf("cwebp resize failed: %w", err)
+	if err := convertWithCache(ctx, r, opts, file, tmpPath, w, h, extraArgs); err != nil {
+		return err
 	}
 
 	// Determine dimensions if either w or h is zero
 	finalW := w
 	finalH := h
 	if w == 0 || h == 0 {
-		dw, dh, derr := getImageDimensions(ctx, tmpPath)
+		dw, dh, derr := getImageDimensions(ctx, r, tmpPath)
 		if derr != nil {
 			// if we can't detect dims, leave zeros as-is but still write file
-			fmt.Fprintf(errOut, "warning: failed to determine dimensions: %v\n", derr)
+			fmt.Fprintf(r.Shell.Stderr, "warning: failed to determine dimensions: %v\n", derr)
 		} else {
 			if w == 0 {
 				finalW = dw
@@ -266,21 +401,101 @@ func imgResize(ctx context.Context, out, errOut io.Writer, file, wStr, hStr stri
 		}
 	}
 
-	ext := filepath.Ext(file)
-	if ext == "" {
-		ext = ".webp"
-	}
-	ext = ext[1:] // remove leading dot
-
-	base := file[:len(file)-len(filepath.Ext(file))]
 	outPath := fmt.Sprintf("%s-w%d-h%d.%s", base, finalW, finalH, ext)
 
 	// copy tmpPath to out
-	if err := copyFile(tmpPath, outPath); err != nil {
+	if err := r.Shell.Copy(tmpPath, outPath); err != nil {
 		return fmt.Errorf("failed to write output file: %w", err)
 	}
 
-	fmt.Fprintf(out, "Successfully resized '%s' to '%s'\n", file, outPath)
+	fmt.Fprintf(r.Shell.Stdout, "Successfully resized '%s' to '%s'\n", file, outPath)
+	return nil
+}
+
+// convertWithCache produces dst from src (converting or resizing to w x h;
+// a single 0 is derived from the other dimension to keep the aspect ratio,
+// and 0 for both keeps the source size) via doConvert, first consulting the
+// content-addressed output cache so a rerun over an unchanged tree can
+// materialize dst with a hardlink instead of reconverting. Dry-run and
+// --no-cache both bypass the cache entirely.
+func convertWithCache(ctx context.Context, r *Runner, opts convertOptions, src, dst string, w, h int, extraArgs []string) error {
+	if opts.noCache || r.Shell.DryRun {
+		return doConvert(ctx, r, opts, src, dst, w, h, extraArgs)
+	}
+
+	c, err := cache.Open(opts.cacheDir)
+	if err != nil {
+		return fmt.Errorf("opening cache: %w", err)
+	}
+	inputDigest, err := cache.HashFile(src)
+	if err != nil {
+		return fmt.Errorf("hashing %s: %w", src, err)
+	}
+	key := cache.Key{
+		InputDigest: inputDigest,
+		ToolVersion: cacheSchemaVersion,
+		Width:       w,
+		Height:      h,
+		Engine:      string(opts.engine),
+		Filter:      string(opts.filter),
+		Quality:     opts.quality,
+	}
+	if opts.engine == pipeline.EngineCwebp {
+		key.CwebpArgs = extraArgs
+	}
+	digest := key.Digest()
+
+	if blobPath, ok := c.Lookup(digest); ok {
+		if err := cache.LinkOrCopy(blobPath, dst); err != nil {
+			return fmt.Errorf("materializing cached output: %w", err)
+		}
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.Dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating cache temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := doConvert(ctx, r, opts, src, tmpPath, w, h, extraArgs); err != nil {
+		return err
+	}
+
+	blobPath, err := c.Store(digest, tmpPath)
+	if err != nil {
+		return fmt.Errorf("storing cache entry: %w", err)
+	}
+	if err := cache.LinkOrCopy(blobPath, dst); err != nil {
+		return fmt.Errorf("materializing cached output: %w", err)
+	}
+	return nil
+}
+
+// doConvert performs the actual native or cwebp conversion from src to dst,
+// with no cache involvement.
+func doConvert(ctx context.Context, r *Runner, opts convertOptions, src, dst string, w, h int, extraArgs []string) error {
+	if opts.engine == pipeline.EngineNative {
+		desc := fmt.Sprintf("convert %s (w=%d h=%d) -> %s", src, w, h, dst)
+		if err := r.Shell.Do(desc, func() error {
+			return pipeline.ConvertFile(src, dst, w, h, opts.filter, float32(opts.quality))
+		}); err != nil {
+			return fmt.Errorf("native conversion failed: %w", err)
+		}
+		return nil
+	}
+
+	args := []string{}
+	if w != 0 || h != 0 {
+		args = append(args, "-resize", strconv.Itoa(w), strconv.Itoa(h))
+	}
+	args = append(args, src, "-o", dst)
+	args = append(args, extraArgs...)
+	if err := r.Shell.Run(ctx, "cwebp", args...); err != nil {
+		return fmt.Errorf("cwebp conversion failed: %w", err)
+	}
 	return nil
 }
 
@@ -291,7 +506,7 @@ func imgResize(ctx context.Context, out, errOut io.Writer, file, wStr, hStr stri
 // The file's extension is preserved (and lowercased). Operates on the provided path.
 // By default this will NOT move the original file; it will create a normalized copy.
 // If deleteOrig is true, the original file will be removed after the copy.
-func imgNormalize(ctx context.Context, out, errOut io.Writer, path string, deleteOrig bool) error {
+func imgNormalize(ctx context.Context, r *Runner, path string, deleteOrig bool) error {
 	// Check file exists and is not a directory
 	fi, err := os.Stat(path)
 	if err != nil {
@@ -322,7 +537,7 @@ func imgNormalize(ctx context.Context, out, errOut io.Writer, path string, delet
 	newPath := filepath.Join(dir, newName)
 	// if name unchanged, nothing to do
 	if newPath == path {
-		fmt.Fprintf(out, "No change: '%s'\n", path)
+		fmt.Fprintf(r.Shell.Stdout, "No change: '%s'\n", path)
 		return nil
 	}
 
@@ -332,27 +547,29 @@ func imgNormalize(ctx context.Context, out, errOut io.Writer, path string, delet
 	}
 
 	// Create normalized copy instead of moving by default
-	if err := copyFile(path, newPath); err != nil {
+	if err := r.Shell.Copy(path, newPath); err != nil {
 		return fmt.Errorf("failed to create normalized file '%s' from '%s': %w", newPath, path, err)
 	}
 
 	if deleteOrig {
-		if err := os.Remove(path); err != nil {
+		if err := r.Shell.Remove(path); err != nil {
 			// If deletion fails, attempt to remove the new file to avoid partial state? Just report error.
 			return fmt.Errorf("created '%s' but failed to remove original '%s': %w", newPath, path, err)
 		}
-		fmt.Fprintf(out, "Renamed '%s' -> '%s'\n", path, newPath)
-	} else {
-		fmt.Fprintf(out, "Created '%s' from '%s'\n", newPath, path)
+		if !r.Shell.DryRun {
+			fmt.Fprintf(r.Shell.Stdout, "Renamed '%s' -> '%s'\n", path, newPath)
+		}
+	} else if !r.Shell.DryRun {
+		fmt.Fprintf(r.Shell.Stdout, "Created '%s' from '%s'\n", newPath, path)
 	}
 	return nil
 }
 
 // imgNormalizeMany normalizes multiple files and aggregates errors.
-func imgNormalizeMany(ctx context.Context, out, errOut io.Writer, files []string, deleteOrig bool) error {
+func imgNormalizeMany(ctx context.Context, r *Runner, files []string, deleteOrig bool) error {
 	var errs []string
 	for _, f := range files {
-		if err := imgNormalize(ctx, out, errOut, f, deleteOrig); err != nil {
+		if err := imgNormalize(ctx, r, f, deleteOrig); err != nil {
 			errs = append(errs, fmt.Sprintf("%s: %v", f, err))
 		}
 	}
@@ -363,7 +580,7 @@ func imgNormalizeMany(ctx context.Context, out, errOut io.Writer, files []string
 }
 
 // imgNormalizeAll normalizes all regular files in the current directory (non-recursive).
-func imgNormalizeAll(ctx context.Context, out, errOut io.Writer, deleteOrig bool) error {
+func imgNormalizeAll(ctx context.Context, r *Runner, deleteOrig bool) error {
 	entries, err := os.ReadDir(".")
 	if err != nil {
 		return fmt.Errorf("reading current directory failed: %w", err)
@@ -375,7 +592,7 @@ func imgNormalizeAll(ctx context.Context, out, errOut io.Writer, deleteOrig bool
 			continue
 		}
 		name := e.Name()
-		if err := imgNormalize(ctx, out, errOut, name, deleteOrig); err != nil {
+		if err := imgNormalize(ctx, r, name, deleteOrig); err != nil {
 			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
 		}
 	}
@@ -407,41 +624,15 @@ func normalizeName(s string) string {
 	return out
 }
 
-func runCmd(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) error {
-	cmd := exec.CommandContext(ctx, name, args...)
-	if stdout != nil {
-		cmd.Stdout = stdout
-	} else {
-		cmd.Stdout = os.Stdout
+func getImageDimensions(ctx context.Context, r *Runner, path string) (int, int, error) {
+	// Prefer decoding the image header in process; only shell out to
+	// `file`/`identify` for formats the standard library can't parse.
+	if w, h, err := pipeline.DecodeConfig(path); err == nil {
+		return w, h, nil
 	}
-	if stderr != nil {
-		cmd.Stderr = stderr
-	} else {
-		cmd.Stderr = os.Stderr
-	}
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
-}
-
-func runShell(ctx context.Context, stdout, stderr io.Writer, cmdStr string) error {
-	cmd := exec.CommandContext(ctx, "bash", "-lc", cmdStr)
-	if stdout != nil {
-		cmd.Stdout = stdout
-	} else {
-		cmd.Stdout = os.Stdout
-	}
-	if stderr != nil {
-		cmd.Stderr = stderr
-	} else {
-		cmd.Stderr = os.Stderr
-	}
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
-}
 
-func getImageDimensions(ctx context.Context, path string) (int, int, error) {
 	// Use the `file` utility and parse "123x456" style output.
-	out, err := exec.CommandContext(ctx, "file", path).Output()
+	out, err := r.Shell.RunOut(ctx, "file", path)
 	if err != nil {
 		return 0, 0, fmt.Errorf("file command failed: %w", err)
 	}
@@ -453,7 +644,7 @@ func getImageDimensions(ctx context.Context, path string) (int, int, error) {
 	if len(m) < 3 {
 		// Fallback to ImageMagick `identify -format %wx%h` if available
 		if _, lookErr := exec.LookPath("identify"); lookErr == nil {
-			out2, err2 := exec.CommandContext(ctx, "identify", "-format", "%wx%h", path).Output()
+			out2, err2 := r.Shell.RunOut(ctx, "identify", "-format", "%wx%h", path)
 			if err2 == nil {
 				m2 := re.FindStringSubmatch(string(out2))
 				if len(m2) >= 3 {
@@ -0,0 +1,171 @@
+// Package walker implements a pure-Go recursive directory walk feeding a
+// bounded worker pool, replacing the external `fd` dependency previously
+// used by convert-all.
+package walker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Options configures which files Collect matches.
+type Options struct {
+	// Recursive descends into subdirectories; otherwise only the
+	// immediate contents of the root are considered.
+	Recursive bool
+	// Include restricts matches to these file extensions (without the
+	// leading dot, case-insensitive). An empty Include matches any file.
+	Include []string
+	// Exclude is a set of glob patterns matched against basenames; a
+	// matching directory is pruned entirely.
+	Exclude []string
+	// FollowSymlinks causes symlinked files and directories to be
+	// resolved and walked; broken symlinks are silently skipped.
+	FollowSymlinks bool
+}
+
+// Collect walks root and returns the sorted paths of regular files
+// matching opts.
+func Collect(root string, opts Options) ([]string, error) {
+	include := make(map[string]bool, len(opts.Include))
+	for _, e := range opts.Include {
+		include[strings.ToLower(strings.TrimPrefix(e, "."))] = true
+	}
+
+	var out []string
+	if err := collect(root, opts, include, &out); err != nil {
+		return nil, err
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func collect(dir string, opts Options, include map[string]bool, out *[]string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		excluded, err := matchAny(opts.Exclude, e.Name())
+		if err != nil {
+			return err
+		}
+		if excluded {
+			continue
+		}
+
+		isDir := e.IsDir()
+		if e.Type()&os.ModeSymlink != 0 {
+			if !opts.FollowSymlinks {
+				continue
+			}
+			info, statErr := os.Stat(path)
+			if statErr != nil {
+				continue // broken symlink
+			}
+			isDir = info.IsDir()
+		}
+
+		if isDir {
+			if !opts.Recursive {
+				continue
+			}
+			if err := collect(path, opts, include, out); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(e.Name()), "."))
+		if len(include) > 0 && !include[ext] {
+			continue
+		}
+		*out = append(*out, path)
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, pat := range patterns {
+		ok, err := filepath.Match(pat, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid exclude pattern %q: %w", pat, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Run processes files concurrently across jobs workers (at least 1),
+// invoking fn for each file. fn returns the number of bytes saved by
+// processing the file (e.g. source size minus output size), which Run
+// accumulates into the progress stream written to progress as each file
+// completes. Per-file errors are aggregated into a single summary error so
+// one bad file does not abort the rest of the batch.
+func Run(files []string, jobs int, progress io.Writer, fn func(file string) (bytesSaved int64, err error)) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	type result struct {
+		path       string
+		bytesSaved int64
+		err        error
+	}
+
+	total := len(files)
+	jobsCh := make(chan string)
+	resultsCh := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobsCh {
+				saved, err := fn(file)
+				resultsCh <- result{path: file, bytesSaved: saved, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobsCh)
+		for _, f := range files {
+			jobsCh <- f
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(resultsCh)
+	}()
+
+	var errs []string
+	var done int
+	var totalSaved int64
+	for res := range resultsCh {
+		done++
+		if res.err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", res.path, res.err))
+		} else {
+			totalSaved += res.bytesSaved
+		}
+		if progress != nil {
+			fmt.Fprintf(progress, "%d/%d converted (%d bytes saved): %s\n", done, total, totalSaved, res.path)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d files failed:\n%s", len(errs), total, strings.Join(errs, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package walker
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectNonRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.jpg"))
+	writeFile(t, filepath.Join(dir, "b.png"))
+	writeFile(t, filepath.Join(dir, "sub", "c.jpg"))
+
+	got, err := Collect(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.jpg"), filepath.Join(dir, "b.png")}
+	sort.Strings(want)
+	if !equal(got, want) {
+		t.Fatalf("Collect() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.jpg"))
+	writeFile(t, filepath.Join(dir, "sub", "c.jpg"))
+
+	got, err := Collect(dir, Options{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.jpg"), filepath.Join(dir, "sub", "c.jpg")}
+	sort.Strings(want)
+	if !equal(got, want) {
+		t.Fatalf("Collect() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.jpg"))
+	writeFile(t, filepath.Join(dir, "b.png"))
+
+	got, err := Collect(dir, Options{Include: []string{"JPG"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.jpg")}
+	if !equal(got, want) {
+		t.Fatalf("Collect() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectExclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.jpg"))
+	writeFile(t, filepath.Join(dir, "sub", "c.jpg"))
+
+	got, err := Collect(dir, Options{Recursive: true, Exclude: []string{"sub"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.jpg")}
+	if !equal(got, want) {
+		t.Fatalf("Collect() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need elevated privileges on windows")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "real", "a.jpg"))
+	if err := os.Symlink(filepath.Join(dir, "real", "a.jpg"), filepath.Join(dir, "link.jpg")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Collect(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("Collect() without FollowSymlinks = %v, want empty", got)
+	}
+
+	got, err = Collect(dir, Options{FollowSymlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "link.jpg")}
+	if !equal(got, want) {
+		t.Fatalf("Collect() with FollowSymlinks = %v, want %v", got, want)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRunProcessesAllFilesConcurrently(t *testing.T) {
+	files := []string{"a", "b", "c", "d"}
+	var processed int64
+
+	var buf bytes.Buffer
+	err := Run(files, 2, &buf, func(file string) (int64, error) {
+		atomic.AddInt64(&processed, 1)
+		return 10, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != int64(len(files)) {
+		t.Fatalf("processed %d files, want %d", processed, len(files))
+	}
+	if !strings.Contains(buf.String(), "40 bytes saved") {
+		t.Fatalf("progress output missing total saved: %q", buf.String())
+	}
+}
+
+func TestRunAggregatesErrors(t *testing.T) {
+	files := []string{"a", "b", "c"}
+
+	err := Run(files, 2, nil, func(file string) (int64, error) {
+		if file == "b" {
+			return 0, os.ErrNotExist
+		}
+		return 0, nil
+	})
+	if err == nil {
+		t.Fatal("expected an aggregated error")
+	}
+	if !strings.Contains(err.Error(), "1 of 3 files failed") {
+		t.Fatalf("error = %v, want mention of 1 of 3 files failed", err)
+	}
+	if !strings.Contains(err.Error(), "b:") {
+		t.Fatalf("error = %v, want mention of failing file b", err)
+	}
+}
+
+func TestRunDefaultsJobsToAtLeastOne(t *testing.T) {
+	err := Run([]string{"a"}, 0, nil, func(file string) (int64, error) {
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
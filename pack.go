@@ -0,0 +1,484 @@
+package cmdimg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jlrickert/cmd-img/internal/walker"
+	"github.com/jlrickert/cmd-img/pipeline"
+	"github.com/jlrickert/cmd-img/renamer"
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/cobra"
+)
+
+// packImageExts are the source extensions pack looks for when walking a
+// directory tree.
+var packImageExts = []string{"jpg", "jpeg", "png", "gif", "bmp", "tiff", "webp"}
+
+// archiveFormat selects the container pack writes and unpack reads.
+type archiveFormat string
+
+const (
+	formatCBZ    archiveFormat = "cbz"
+	formatZip    archiveFormat = "zip"
+	formatTarZst archiveFormat = "tar.zst"
+)
+
+// parseArchiveFormat validates a user-supplied --format value.
+func parseArchiveFormat(s string) (archiveFormat, error) {
+	switch archiveFormat(s) {
+	case formatCBZ, formatZip, formatTarZst:
+		return archiveFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown format %q (want %q, %q or %q)", s, formatCBZ, formatZip, formatTarZst)
+	}
+}
+
+// archiveFormatFromPath infers a format from an archive's file extension,
+// used by unpack when --format isn't given explicitly.
+func archiveFormatFromPath(path string) (archiveFormat, error) {
+	switch {
+	case strings.HasSuffix(path, ".tar.zst"):
+		return formatTarZst, nil
+	case strings.HasSuffix(path, ".cbz"):
+		return formatCBZ, nil
+	case strings.HasSuffix(path, ".zip"):
+		return formatZip, nil
+	default:
+		return "", fmt.Errorf("cannot infer archive format from %q (expected .cbz, .zip or .tar.zst)", path)
+	}
+}
+
+// packSortOrder selects how pack orders entries within the archive.
+type packSortOrder string
+
+const (
+	sortNatural packSortOrder = "natural"
+	sortName    packSortOrder = "name"
+	sortMtime   packSortOrder = "mtime"
+)
+
+// parsePackSortOrder validates a user-supplied --sort value.
+func parsePackSortOrder(s string) (packSortOrder, error) {
+	switch packSortOrder(s) {
+	case sortNatural, sortName, sortMtime:
+		return packSortOrder(s), nil
+	default:
+		return "", fmt.Errorf("unknown sort order %q (want %q, %q or %q)", s, sortNatural, sortName, sortMtime)
+	}
+}
+
+// parseResize parses a "WxH" dimension string as used by --resize. A zero
+// component (e.g. "800x0") preserves the source's aspect ratio for that
+// dimension, matching imgResize's width/height=0 convention. An empty
+// string means "don't resize".
+func parseResize(s string) (width, height int, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	parts := strings.SplitN(s, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid --resize %q (want WxH)", s)
+	}
+	width, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid --resize width %q", parts[0])
+	}
+	height, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid --resize height %q", parts[1])
+	}
+	if width < 0 || height < 0 {
+		return 0, 0, fmt.Errorf("--resize dimensions must be non-negative")
+	}
+	return width, height, nil
+}
+
+// newPackCmd builds the `pack` subcommand: it converts every image under a
+// directory tree to WebP and streams the results directly into an archive,
+// reusing the native pipeline instead of scattering converted files on
+// disk. Dry-run and trace come from r's Shell, so the global -n/-x flags
+// apply here too.
+func newPackCmd(r *Runner) *cobra.Command {
+	var (
+		out         string
+		formatStr   string
+		resize      string
+		sortStr     string
+		stripPrefix bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "pack <dir>",
+		Short: "Convert a directory tree of images and write them into a .cbz/.zip/.tar.zst archive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+
+			format, err := parseArchiveFormat(formatStr)
+			if err != nil {
+				return err
+			}
+			order, err := parsePackSortOrder(sortStr)
+			if err != nil {
+				return err
+			}
+			width, height, err := parseResize(resize)
+			if err != nil {
+				return err
+			}
+
+			filterStr, _ := cmd.Flags().GetString("filter")
+			filter, err := pipeline.ParseFilter(filterStr)
+			if err != nil {
+				return err
+			}
+			quality, _ := cmd.Flags().GetFloat64("quality")
+
+			if out == "" {
+				out = strings.TrimSuffix(filepath.Base(filepath.Clean(dir)), filepath.Ext(dir)) + "." + string(format)
+			}
+
+			files, err := walker.Collect(dir, walker.Options{Recursive: true, Include: packImageExts})
+			if err != nil {
+				return fmt.Errorf("walking %s: %w", dir, err)
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no image files found under %s", dir)
+			}
+			if err := sortPackFiles(files, order); err != nil {
+				return err
+			}
+
+			desc := fmt.Sprintf("pack %d image(s) -> %s", len(files), out)
+			err = r.Shell.Do(desc, func() error {
+				archiveOut, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("creating %s: %w", out, err)
+				}
+				defer archiveOut.Close()
+
+				switch format {
+				case formatCBZ, formatZip:
+					return packZip(archiveOut, files, dir, stripPrefix, width, height, filter, quality)
+				case formatTarZst:
+					return packTarZst(archiveOut, files, dir, stripPrefix, width, height, filter, quality)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if !r.Shell.DryRun {
+				fmt.Fprintf(cmd.OutOrStdout(), "Wrote %d image(s) to %s\n", len(files), out)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "output archive path (default: <dir>.<format>)")
+	cmd.Flags().StringVar(&formatStr, "format", string(formatCBZ), "archive format: cbz, zip or tar.zst")
+	cmd.Flags().StringVar(&resize, "resize", "", "resize images to WxH before packing (0 for a dimension auto-scales it)")
+	cmd.Flags().StringVar(&sortStr, "sort", string(sortNatural), "entry order: natural, name or mtime")
+	cmd.Flags().BoolVar(&stripPrefix, "strip-prefix", false, "drop each file's directory component so every entry lands at the archive root")
+
+	return cmd
+}
+
+// sortPackFiles reorders files in place according to order. walker.Collect
+// already returns files in lexical (name) order, so sortName is a no-op.
+func sortPackFiles(files []string, order packSortOrder) error {
+	switch order {
+	case sortName:
+		// already lexically sorted by walker.Collect
+	case sortNatural:
+		sort.Slice(files, func(i, j int) bool { return renamer.NaturalLess(files[i], files[j]) })
+	case sortMtime:
+		modTimes := make(map[string]time.Time, len(files))
+		for _, f := range files {
+			fi, err := os.Stat(f)
+			if err != nil {
+				return fmt.Errorf("stat %s: %w", f, err)
+			}
+			modTimes[f] = fi.ModTime()
+		}
+		sort.Slice(files, func(i, j int) bool { return modTimes[files[i]].Before(modTimes[files[j]]) })
+	}
+	return nil
+}
+
+// archiveEntryName derives an in-archive entry name for src relative to
+// root, with its extension replaced by .webp. When stripPrefix is set the
+// directory component is dropped so every entry lands at the archive root.
+func archiveEntryName(src, root string, stripPrefix bool) string {
+	webpPath := src[:len(src)-len(filepath.Ext(src))] + ".webp"
+	if stripPrefix {
+		return filepath.Base(webpPath)
+	}
+	rel, err := filepath.Rel(root, webpPath)
+	if err != nil {
+		return filepath.ToSlash(filepath.Base(webpPath))
+	}
+	return filepath.ToSlash(rel)
+}
+
+// encodeEntry decodes src, optionally resizes it, and writes it to w as
+// WebP, reusing the native pipeline so pack never touches a scratch file.
+func encodeEntry(src string, w io.Writer, width, height int, filter pipeline.Filter, quality float64) error {
+	img, err := pipeline.Decode(src)
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", src, err)
+	}
+	if width != 0 || height != 0 {
+		img = pipeline.Resize(img, width, height, filter)
+	}
+	if err := pipeline.EncodeWebP(w, img, float32(quality)); err != nil {
+		return fmt.Errorf("encoding %s: %w", src, err)
+	}
+	return nil
+}
+
+// packZip streams each converted image straight into a zip writer backing
+// w; cbz is just a zip with a comic-reader-friendly extension.
+func packZip(w io.Writer, files []string, root string, stripPrefix bool, width, height int, filter pipeline.Filter, quality float64) error {
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		name := archiveEntryName(f, root, stripPrefix)
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			return fmt.Errorf("adding %s to archive: %w", name, err)
+		}
+		if err := encodeEntry(f, fw, width, height, filter, quality); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// packTarZst writes each converted image into a zstd-compressed tar
+// backing w. Unlike packZip this buffers each entry in memory first,
+// since tar headers must record the entry size up front.
+func packTarZst(w io.Writer, files []string, root string, stripPrefix bool, width, height int, filter pipeline.Filter, quality float64) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("creating zstd writer: %w", err)
+	}
+	defer zw.Close()
+
+	tw := tar.NewWriter(zw)
+	defer tw.Close()
+
+	var buf bytes.Buffer
+	for _, f := range files {
+		name := archiveEntryName(f, root, stripPrefix)
+		buf.Reset()
+		if err := encodeEntry(f, &buf, width, height, filter, quality); err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(buf.Len())}); err != nil {
+			return fmt.Errorf("writing tar header for %s: %w", name, err)
+		}
+		if _, err := tw.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("writing %s to archive: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// newUnpackCmd builds the `unpack` subcommand: it extracts an archive
+// written by pack (or any similarly laid out cbz/zip/tar.zst), normalizing
+// each entry's filename via normalizeName as it's written to disk.
+// Dry-run and trace come from r's Shell, so the global -n/-x flags apply
+// here too.
+func newUnpackCmd(r *Runner) *cobra.Command {
+	var (
+		outDir    string
+		formatStr string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "unpack <archive>",
+		Short: "Extract an archive written by pack, normalizing each entry's filename",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			archivePath := args[0]
+
+			format := archiveFormat(formatStr)
+			if format == "" {
+				f, err := archiveFormatFromPath(archivePath)
+				if err != nil {
+					return err
+				}
+				format = f
+			}
+
+			dir := outDir
+			if dir == "" {
+				dir = strings.TrimSuffix(filepath.Base(archivePath), filepath.Ext(archivePath))
+			}
+
+			var n int
+			desc := fmt.Sprintf("unpack %s -> %s", archivePath, dir)
+			err := r.Shell.Do(desc, func() error {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					return fmt.Errorf("creating %s: %w", dir, err)
+				}
+				var err error
+				switch format {
+				case formatCBZ, formatZip:
+					n, err = unpackZip(archivePath, dir)
+				case formatTarZst:
+					n, err = unpackTarZst(archivePath, dir)
+				default:
+					err = fmt.Errorf("unsupported format %q", format)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			if !r.Shell.DryRun {
+				fmt.Fprintf(cmd.OutOrStdout(), "Extracted %d file(s) to %s\n", n, dir)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outDir, "out", "", "output directory (default: archive name without its extension)")
+	cmd.Flags().StringVar(&formatStr, "format", "", "archive format: cbz, zip or tar.zst (default: inferred from the archive's extension)")
+
+	return cmd
+}
+
+// extractEntry normalizes each path segment of entryName via normalizeName,
+// preserving the final segment's extension, and writes the content produced
+// by write under outDir. The entry's relative directory structure is kept
+// intact (not flattened to its base name) so same-named files in different
+// subdirectories - the normal per-chapter comic/manga layout pack preserves -
+// land at different paths instead of clobbering each other; if normalizing
+// still produces a path that already exists, extraction fails rather than
+// silently truncating it. Path-traversal segments ("..") are rejected.
+func extractEntry(entryName, outDir string, write func(w io.Writer) error) error {
+	parts := strings.Split(filepath.ToSlash(entryName), "/")
+
+	segments := make([]string, 0, len(parts))
+	for _, p := range parts {
+		switch p {
+		case "", ".":
+			continue
+		case "..":
+			return fmt.Errorf("refusing to extract %q: contains \"..\"", entryName)
+		}
+		segments = append(segments, p)
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("refusing to extract %q: empty entry name", entryName)
+	}
+
+	last := len(segments) - 1
+	ext := strings.ToLower(filepath.Ext(segments[last]))
+	segments[last] = normalizeName(strings.TrimSuffix(segments[last], filepath.Ext(segments[last]))) + ext
+	for i := 0; i < last; i++ {
+		segments[i] = normalizeName(segments[i])
+	}
+
+	dstPath := filepath.Join(append([]string{outDir}, segments...)...)
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(dstPath), err)
+	}
+	if _, err := os.Stat(dstPath); err == nil {
+		return fmt.Errorf("refusing to extract %q: %s already exists", entryName, dstPath)
+	}
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", dstPath, err)
+	}
+	defer out.Close()
+
+	if err := write(out); err != nil {
+		return fmt.Errorf("extracting %s: %w", entryName, err)
+	}
+	return nil
+}
+
+// unpackZip extracts every regular file entry of the zip/cbz archive at
+// archivePath into outDir.
+func unpackZip(archivePath, outDir string) (int, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("opening %s: %w", archivePath, err)
+	}
+	defer zr.Close()
+
+	n := 0
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		err := extractEntry(f.Name, outDir, func(w io.Writer) error {
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			_, err = io.Copy(w, rc)
+			return err
+		})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// unpackTarZst extracts every regular file entry of the zstd-compressed tar
+// archive at archivePath into outDir.
+func unpackTarZst(archivePath, outDir string) (int, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		return 0, fmt.Errorf("opening zstd stream: %w", err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	n := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("reading tar entry: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := extractEntry(hdr.Name, outDir, func(w io.Writer) error {
+			_, err := io.Copy(w, tr)
+			return err
+		}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,172 @@
+package cmdimg
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseArchiveFormat(t *testing.T) {
+	if _, err := parseArchiveFormat("bogus"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+	for _, s := range []string{"cbz", "zip", "tar.zst"} {
+		if got, err := parseArchiveFormat(s); err != nil || string(got) != s {
+			t.Fatalf("parseArchiveFormat(%q) = %v, %v", s, got, err)
+		}
+	}
+}
+
+func TestArchiveFormatFromPath(t *testing.T) {
+	cases := map[string]archiveFormat{
+		"book.cbz":     formatCBZ,
+		"book.zip":     formatZip,
+		"book.tar.zst": formatTarZst,
+	}
+	for path, want := range cases {
+		got, err := archiveFormatFromPath(path)
+		if err != nil || got != want {
+			t.Errorf("archiveFormatFromPath(%q) = %v, %v, want %v", path, got, err, want)
+		}
+	}
+	if _, err := archiveFormatFromPath("book.rar"); err == nil {
+		t.Fatal("expected an error for an unrecognized extension")
+	}
+}
+
+func TestParseResize(t *testing.T) {
+	if w, h, err := parseResize(""); err != nil || w != 0 || h != 0 {
+		t.Fatalf("parseResize(\"\") = %d,%d,%v, want 0,0,nil", w, h, err)
+	}
+	if w, h, err := parseResize("800x600"); err != nil || w != 800 || h != 600 {
+		t.Fatalf("parseResize(800x600) = %d,%d,%v", w, h, err)
+	}
+	if w, h, err := parseResize("800x0"); err != nil || w != 800 || h != 0 {
+		t.Fatalf("parseResize(800x0) = %d,%d,%v", w, h, err)
+	}
+	if _, _, err := parseResize("800"); err == nil {
+		t.Fatal("expected an error for a malformed --resize value")
+	}
+	if _, _, err := parseResize("-800x600"); err == nil {
+		t.Fatal("expected an error for a negative dimension")
+	}
+}
+
+func TestArchiveEntryName(t *testing.T) {
+	root := "/tree"
+	src := "/tree/ch1/001.jpg"
+	if got, want := archiveEntryName(src, root, false), "ch1/001.webp"; got != want {
+		t.Errorf("archiveEntryName = %q, want %q", got, want)
+	}
+	if got, want := archiveEntryName(src, root, true), "001.webp"; got != want {
+		t.Errorf("archiveEntryName with stripPrefix = %q, want %q", got, want)
+	}
+}
+
+// TestExtractEntryPreservesDirectoryStructure guards against the bug where
+// extractEntry flattened every entry to its base name, so same-named files
+// from different subdirectories (the normal per-chapter comic/manga layout)
+// clobbered each other on extraction.
+func TestExtractEntryPreservesDirectoryStructure(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(content string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := w.Write([]byte(content))
+			return err
+		}
+	}
+
+	if err := extractEntry("ch1/001.jpg", dir, write("chapter one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractEntry("ch2/001.jpg", dir, write("chapter two")); err != nil {
+		t.Fatal(err)
+	}
+
+	got1, err := os.ReadFile(filepath.Join(dir, "ch1", "001.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got2, err := os.ReadFile(filepath.Join(dir, "ch2", "001.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got1) != "chapter one" {
+		t.Errorf("ch1/001.jpg = %q, want %q", got1, "chapter one")
+	}
+	if string(got2) != "chapter two" {
+		t.Errorf("ch2/001.jpg = %q, want %q", got2, "chapter two")
+	}
+}
+
+func TestExtractEntryRejectsPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	err := extractEntry("../evil.jpg", dir, func(w io.Writer) error { return nil })
+	if err == nil {
+		t.Fatal("expected extractEntry to reject a \"..\" entry name")
+	}
+}
+
+func TestExtractEntryRefusesCollision(t *testing.T) {
+	dir := t.TempDir()
+	write := func(w io.Writer) error {
+		_, err := w.Write([]byte("x"))
+		return err
+	}
+	if err := extractEntry("a.jpg", dir, write); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractEntry("a.jpg", dir, write); err == nil {
+		t.Fatal("expected extractEntry to refuse overwriting an existing file")
+	}
+}
+
+func TestUnpackZipPreservesDirectoryStructure(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range []struct{ name, content string }{
+		{"ch1/001.jpg", "one"},
+		{"ch2/001.jpg", "two"},
+	} {
+		fw, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "book.zip")
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	n, err := unpackZip(archivePath, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("unpackZip extracted %d files, want 2", n)
+	}
+
+	got1, err := os.ReadFile(filepath.Join(outDir, "ch1", "001.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got2, err := os.ReadFile(filepath.Join(outDir, "ch2", "001.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got1) != "one" || string(got2) != "two" {
+		t.Fatalf("got %q, %q, want distinct per-chapter contents", got1, got2)
+	}
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEngine(t *testing.T) {
+	if _, err := ParseEngine("bogus"); err == nil {
+		t.Fatal("expected an error for an unknown engine")
+	}
+	if e, err := ParseEngine("native"); err != nil || e != EngineNative {
+		t.Fatalf("ParseEngine(native) = %v, %v", e, err)
+	}
+	if e, err := ParseEngine("cwebp"); err != nil || e != EngineCwebp {
+		t.Fatalf("ParseEngine(cwebp) = %v, %v", e, err)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	if _, err := ParseFilter("bogus"); err == nil {
+		t.Fatal("expected an error for an unknown filter")
+	}
+	if f, err := ParseFilter("bilinear"); err != nil || f != FilterBilinear {
+		t.Fatalf("ParseFilter(bilinear) = %v, %v", f, err)
+	}
+}
+
+func TestResizePreservesAspectRatio(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	out := Resize(img, 100, 0, FilterCatmullRom)
+	b := out.Bounds()
+	if b.Dx() != 100 || b.Dy() != 50 {
+		t.Fatalf("Resize(w=100) = %dx%d, want 100x50", b.Dx(), b.Dy())
+	}
+}
+
+func TestResizeZeroDimensionsIsNoop(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	out := Resize(img, 0, 0, FilterCatmullRom)
+	if out != image.Image(img) {
+		t.Fatal("Resize with both dimensions zero should return img unchanged")
+	}
+}
+
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0, A: 255})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeAndDecodeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	writePNG(t, path, 16, 8)
+
+	w, h, err := DecodeConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != 16 || h != 8 {
+		t.Fatalf("DecodeConfig = %dx%d, want 16x8", w, h)
+	}
+
+	img, err := Decode(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 8 {
+		t.Fatalf("Decode bounds = %dx%d, want 16x8", b.Dx(), b.Dy())
+	}
+}
+
+// TestEncodeWebPMatchesAvailable checks that EncodeWebP's behavior is
+// consistent with what Available() reports: it should succeed when the
+// native encoder is available, and fail with a clear error when it isn't
+// (a CGO_ENABLED=0 build).
+func TestEncodeWebPMatchesAvailable(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	var buf bytes.Buffer
+	err := EncodeWebP(&buf, img, 80)
+
+	if Available() {
+		if err != nil {
+			t.Fatalf("EncodeWebP failed though Available() is true: %v", err)
+		}
+		if buf.Len() == 0 {
+			t.Fatal("EncodeWebP wrote no output")
+		}
+	} else if err == nil {
+		t.Fatal("EncodeWebP should fail when Available() is false")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build cgo
+
+package pipeline
+
+import (
+	"image"
+	"io"
+
+	"github.com/chai2010/webp"
+)
+
+// Available reports whether the native Go WebP encoder can be used in this
+// build. It links libwebp via cgo, so it's only available in CGO_ENABLED=1
+// builds with a C toolchain; see webp_nocgo.go for the CGO_ENABLED=0 case.
+func Available() bool {
+	return true
+}
+
+// EncodeWebP writes img to w as WebP using the native (cgo) encoder. quality
+// is in the range [0, 100], matching cwebp's -q flag.
+func EncodeWebP(w io.Writer, img image.Image, quality float32) error {
+	return webp.Encode(w, img, &webp.Options{Quality: quality})
+}
@@ -0,0 +1,26 @@
+//go:build !cgo
+
+package pipeline
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+// errNativeEncoderUnavailable is returned by EncodeWebP in CGO_ENABLED=0
+// builds, where the cgo-based encoder in github.com/chai2010/webp can't be
+// linked in.
+var errNativeEncoderUnavailable = errors.New("native WebP encoder requires building with CGO_ENABLED=1 (cgo); use --engine cwebp instead")
+
+// Available reports whether the native Go WebP encoder can be used in this
+// build. This build was compiled with CGO_ENABLED=0, so it's not; callers
+// should fall back to EngineCwebp. See webp_cgo.go for the CGO_ENABLED=1 case.
+func Available() bool {
+	return false
+}
+
+// EncodeWebP always fails in this build; see errNativeEncoderUnavailable.
+func EncodeWebP(w io.Writer, img image.Image, quality float32) error {
+	return errNativeEncoderUnavailable
+}
@@ -0,0 +1,161 @@
+package cmdimg
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/jlrickert/cmd-img/renamer"
+	"github.com/spf13/cobra"
+)
+
+// newRenameCmd builds the `rename` subcommand: a regex/transform-driven
+// batch rename engine with dry-run preview and an undo journal. Dry-run and
+// trace come from r's Shell, so the global -n/-x flags apply here too.
+func newRenameCmd(r *Runner) *cobra.Command {
+	var (
+		pattern        string
+		replacement    string
+		lower          bool
+		collapseHyphen bool
+		stripDiacritic bool
+		ascii          bool
+		recursive      bool
+		includeDirs    bool
+		excludes       []string
+		naturalSort    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rename <path> [path...]",
+		Short: "Batch rename files using regex patterns and predefined transforms",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := renamer.CollectFiles(args, recursive, includeDirs, excludes)
+			if err != nil {
+				return err
+			}
+
+			opts := renamer.Options{NaturalSort: naturalSort}
+			if pattern != "" {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return fmt.Errorf("invalid --pattern: %w", err)
+				}
+				opts.Pattern = re
+				opts.Replacement = replacement
+			}
+			if lower {
+				opts.Transforms = append(opts.Transforms, renamer.Lower)
+			}
+			if stripDiacritic {
+				opts.Transforms = append(opts.Transforms, renamer.StripDiacritics)
+			}
+			if ascii {
+				opts.Transforms = append(opts.Transforms, renamer.ASCII)
+			}
+			// Collapse hyphens last so it cleans up any hyphens introduced
+			// by earlier transforms or the pattern replacement.
+			if collapseHyphen {
+				opts.Transforms = append(opts.Transforms, renamer.CollapseHyphens)
+			}
+
+			plan, err := renamer.Plan(files, opts)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			changed := 0
+			for _, rn := range plan {
+				if rn.Old == rn.New {
+					continue
+				}
+				changed++
+				fmt.Fprintf(out, "%s -> %s\n", rn.Old, rn.New)
+			}
+			if changed == 0 {
+				fmt.Fprintln(out, "Nothing to rename")
+				return nil
+			}
+			if r.Shell.DryRun {
+				return nil
+			}
+
+			desc := fmt.Sprintf("apply %d rename(s)", changed)
+			if err := r.Shell.Do(desc, func() error { return renamer.Apply(plan) }); err != nil {
+				return err
+			}
+
+			journalPath, err := renamer.WriteJournal(plan, time.Now())
+			if err != nil {
+				return fmt.Errorf("rename completed but failed to write undo journal: %w", err)
+			}
+			fmt.Fprintf(out, "Wrote undo journal: %s\n", journalPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&pattern, "pattern", "", "regexp pattern to match against each filename")
+	cmd.Flags().StringVar(&replacement, "replacement", "", "replacement template (Go regexp syntax, e.g. ${1})")
+	cmd.Flags().BoolVar(&lower, "lower", false, "lowercase filenames")
+	cmd.Flags().BoolVar(&collapseHyphen, "collapse-hyphens", false, "collapse repeated hyphens")
+	cmd.Flags().BoolVar(&stripDiacritic, "strip-diacritics", false, `strip diacritical marks (e.g. "café" -> "cafe")`)
+	cmd.Flags().BoolVar(&ascii, "ascii", false, "drop any remaining non-ASCII characters")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "recurse into directories")
+	cmd.Flags().BoolVar(&includeDirs, "include-dirs", false, "also rename directory names, not just files")
+	cmd.Flags().StringArrayVar(&excludes, "exclude", nil, "glob pattern to exclude, matched against basenames (repeatable)")
+	cmd.Flags().BoolVar(&naturalSort, "natural-sort", false, "process files in natural sort order instead of lexical order")
+
+	return cmd
+}
+
+// newUndoCmd builds the `undo` subcommand, which replays a rename journal
+// written by `rename` in reverse. Dry-run and trace come from r's Shell.
+func newUndoCmd(r *Runner) *cobra.Command {
+	var (
+		last       bool
+		journalArg string
+		force      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "undo [--last | --file <journal>]",
+		Short: "Undo a previous rename batch by replaying its undo journal in reverse",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := journalArg
+			if path == "" {
+				if !last {
+					return fmt.Errorf("specify --last or --file <journal>")
+				}
+				p, err := renamer.LatestJournal()
+				if err != nil {
+					return err
+				}
+				path = p
+			}
+
+			j, err := renamer.ReadJournal(path)
+			if err != nil {
+				return err
+			}
+
+			desc := fmt.Sprintf("undo %d rename(s) from %s", len(j.Entries), filepath.Base(path))
+			if err := r.Shell.Do(desc, func() error { return renamer.Undo(j, force) }); err != nil {
+				return err
+			}
+			if !r.Shell.DryRun {
+				fmt.Fprintf(cmd.OutOrStdout(), "Undid %d rename(s) from %s\n", len(j.Entries), filepath.Base(path))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&last, "last", false, "undo the most recently written rename journal")
+	cmd.Flags().StringVar(&journalArg, "file", "", "undo a specific journal file")
+	cmd.Flags().BoolVar(&force, "force", false, "undo even if a file's size/mtime no longer match what was recorded")
+
+	return cmd
+}
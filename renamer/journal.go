@@ -0,0 +1,137 @@
+package renamer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry records one applied rename so it can be undone later.
+type Entry struct {
+	Old   string    `json:"old"`
+	New   string    `json:"new"`
+	Size  int64     `json:"size"`
+	MTime time.Time `json:"mtime"`
+}
+
+// Journal is the undo log written after a real (non-dry-run) rename batch.
+type Journal struct {
+	Entries []Entry `json:"entries"`
+}
+
+// StateDir returns $XDG_STATE_HOME/cmd-img/undo, falling back to
+// ~/.local/state/cmd-img/undo per the XDG base directory spec.
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "cmd-img", "undo"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "cmd-img", "undo"), nil
+}
+
+// WriteJournal records renames to a new timestamped journal file under
+// StateDir and returns its path. Renames where Old == New are skipped since
+// nothing happened to undo.
+func WriteJournal(renames []Rename, now time.Time) (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating undo journal dir: %w", err)
+	}
+
+	j := Journal{Entries: make([]Entry, 0, len(renames))}
+	for _, r := range renames {
+		if r.Old == r.New {
+			continue
+		}
+		fi, err := os.Stat(r.New)
+		if err != nil {
+			return "", fmt.Errorf("stat %s after rename: %w", r.New, err)
+		}
+		j.Entries = append(j.Entries, Entry{Old: r.Old, New: r.New, Size: fi.Size(), MTime: fi.ModTime()})
+	}
+
+	path := filepath.Join(dir, now.UTC().Format("20060102T150405.000000000Z")+".json")
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("writing undo journal: %w", err)
+	}
+	return path, nil
+}
+
+// LatestJournal returns the path to the most recently written journal file
+// in StateDir, relying on the fact that journal filenames are timestamps
+// and therefore sort chronologically.
+func LatestJournal() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("reading undo journal dir: %w", err)
+	}
+	var latest string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		if e.Name() > latest {
+			latest = e.Name()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no undo journal found in %s", dir)
+	}
+	return filepath.Join(dir, latest), nil
+}
+
+// ReadJournal loads a journal file from path.
+func ReadJournal(path string) (Journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Journal{}, err
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return Journal{}, fmt.Errorf("parsing undo journal %s: %w", path, err)
+	}
+	return j, nil
+}
+
+// Undo renames each journal entry's New back to Old. It refuses to touch any
+// file whose current size/mtime no longer match what was recorded at rename
+// time, unless force is true, checking every entry before renaming any of
+// them so a stale entry aborts the whole undo atomically. The actual renames
+// are run back through Apply, so an undo that reverses a swap or rotation
+// (as Apply itself can produce) is ordered and cycle-broken the same way the
+// original rename batch was, instead of losing data to a naive reverse replay.
+func Undo(j Journal, force bool) error {
+	if !force {
+		for _, e := range j.Entries {
+			fi, err := os.Stat(e.New)
+			if err != nil {
+				return fmt.Errorf("stat %s: %w", e.New, err)
+			}
+			if fi.Size() != e.Size || !fi.ModTime().Equal(e.MTime) {
+				return fmt.Errorf("%s has changed since it was renamed (size/mtime mismatch); use --force to undo anyway", e.New)
+			}
+		}
+	}
+
+	renames := make([]Rename, len(j.Entries))
+	for i, e := range j.Entries {
+		renames[i] = Rename{Old: e.New, New: e.Old}
+	}
+	return Apply(renames)
+}
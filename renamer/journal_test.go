@@ -0,0 +1,88 @@
+package renamer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func journalEntry(t *testing.T, old, new string) Entry {
+	t.Helper()
+	fi, err := os.Stat(new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Entry{Old: old, New: new, Size: fi.Size(), MTime: fi.ModTime()}
+}
+
+// TestUndoSwapCycle guards against the bug where Undo naively replayed a
+// journal in reverse: a swap (a<->b) applies cleanly via Apply but a flat
+// reverse replay of its journal hits the same collision every rename in the
+// swap runs into, and errors out instead of restoring the original names.
+func TestUndoSwapCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	writeFile(t, a, "A")
+	writeFile(t, b, "B")
+
+	plan := []Rename{
+		{Old: a, New: b},
+		{Old: b, New: a},
+	}
+	if err := Apply(plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := readFile(t, a); got != "B" {
+		t.Fatalf("after Apply, a.txt = %q, want %q", got, "B")
+	}
+	if got := readFile(t, b); got != "A" {
+		t.Fatalf("after Apply, b.txt = %q, want %q", got, "A")
+	}
+
+	j := Journal{Entries: []Entry{
+		journalEntry(t, a, b),
+		journalEntry(t, b, a),
+	}}
+
+	if err := Undo(j, false); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if got := readFile(t, a); got != "A" {
+		t.Errorf("after Undo, a.txt = %q, want %q", got, "A")
+	}
+	if got := readFile(t, b); got != "B" {
+		t.Errorf("after Undo, b.txt = %q, want %q", got, "B")
+	}
+}
+
+func TestUndoRefusesOnMismatch(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	writeFile(t, a, "A")
+	if err := Apply([]Rename{{Old: a, New: b}}); err != nil {
+		t.Fatal(err)
+	}
+
+	e := journalEntry(t, a, b)
+	// Simulate b.txt having been modified after the rename.
+	time.Sleep(10 * time.Millisecond)
+	writeFile(t, b, "changed")
+
+	j := Journal{Entries: []Entry{e}}
+	if err := Undo(j, false); err == nil {
+		t.Fatal("expected Undo to refuse a size/mtime mismatch")
+	}
+	if got := readFile(t, b); got != "changed" {
+		t.Fatalf("b.txt was touched despite the refusal: %q", got)
+	}
+
+	if err := Undo(j, true); err != nil {
+		t.Fatalf("Undo with force: %v", err)
+	}
+	if got := readFile(t, a); got != "changed" {
+		t.Fatalf("a.txt = %q, want %q", got, "changed")
+	}
+}
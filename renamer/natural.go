@@ -0,0 +1,86 @@
+package renamer
+
+import (
+	"strconv"
+	"strings"
+)
+
+// run is a maximal substring of either all-digit or all-non-digit runes.
+type run struct {
+	text    string
+	isDigit bool
+}
+
+func splitRuns(s string) []run {
+	var runs []run
+	var b strings.Builder
+	curDigit := false
+	for i, r := range s {
+		d := r >= '0' && r <= '9'
+		if i > 0 && d != curDigit {
+			runs = append(runs, run{b.String(), curDigit})
+			b.Reset()
+		}
+		curDigit = d
+		b.WriteRune(r)
+	}
+	if b.Len() > 0 {
+		runs = append(runs, run{b.String(), curDigit})
+	}
+	return runs
+}
+
+// compareRun orders two runs of the same kind. Digit runs compare
+// numerically, with the shorter (fewer leading zeros) run winning a
+// numeric tie. Non-digit runs compare case-insensitively, then
+// case-sensitively to keep the ordering total.
+func compareRun(a, b run) int {
+	if a.isDigit && b.isDigit {
+		an, bn := parseDigits(a.text), parseDigits(b.text)
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+		if len(a.text) != len(b.text) {
+			if len(a.text) < len(b.text) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.text, b.text)
+	}
+	if a.isDigit != b.isDigit {
+		if a.isDigit {
+			return -1
+		}
+		return 1
+	}
+	if c := strings.Compare(strings.ToLower(a.text), strings.ToLower(b.text)); c != 0 {
+		return c
+	}
+	return strings.Compare(a.text, b.text)
+}
+
+func parseDigits(s string) uint64 {
+	n, err := strconv.ParseUint(strings.TrimLeft(s, "0"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// NaturalLess reports whether a should sort before b using natural order:
+// names are split into runs of digits and non-digits, digit runs compare
+// numerically (ties broken by leading-zero-sensitive text), and non-digit
+// runs compare case-insensitively then case-sensitively.
+func NaturalLess(a, b string) bool {
+	ar, br := splitRuns(a), splitRuns(b)
+	for i := 0; i < len(ar) && i < len(br); i++ {
+		if c := compareRun(ar[i], br[i]); c != 0 {
+			return c < 0
+		}
+	}
+	return len(ar) < len(br)
+}
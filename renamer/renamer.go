@@ -0,0 +1,281 @@
+// Package renamer implements a batch file-renaming engine: regex and
+// predefined transforms build a rename plan, which can be previewed with
+// --dry-run, applied atomically, and later undone via a journal.
+package renamer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Transform is a named, composable filename transformation. Transforms run
+// on the basename after any --pattern/--replacement substitution.
+type Transform func(string) string
+
+// Lower lowercases a name.
+func Lower(s string) string { return strings.ToLower(s) }
+
+var hyphenRunRe = regexp.MustCompile(`-+`)
+
+// CollapseHyphens collapses runs of hyphens into a single hyphen.
+func CollapseHyphens(s string) string { return hyphenRunRe.ReplaceAllString(s, "-") }
+
+// StripDiacritics removes combining diacritical marks, e.g. "café" -> "cafe".
+func StripDiacritics(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return out
+}
+
+// ASCII drops any remaining non-ASCII runes.
+func ASCII(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r <= unicode.MaxASCII {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Rename is one planned old-path -> new-path move.
+type Rename struct {
+	Old string
+	New string
+}
+
+// Options configures how a rename Plan is built.
+type Options struct {
+	// Pattern, if set, is applied to each basename via ReplaceAllString
+	// before Replacement (Go regexp "${1}" capture syntax).
+	Pattern     *regexp.Regexp
+	Replacement string
+	Transforms  []Transform
+	NaturalSort bool
+}
+
+// Plan computes the renames for paths, applying opts.Pattern/Replacement
+// followed by opts.Transforms to each basename, and detects collisions
+// before any file is touched. Directory components are left untouched;
+// only the basename of each path is transformed.
+func Plan(paths []string, opts Options) ([]Rename, error) {
+	sorted := append([]string(nil), paths...)
+	if opts.NaturalSort {
+		sort.Slice(sorted, func(i, j int) bool { return NaturalLess(sorted[i], sorted[j]) })
+	} else {
+		sort.Strings(sorted)
+	}
+
+	renames := make([]Rename, 0, len(sorted))
+	destOf := make(map[string]string, len(sorted)) // new path -> old path
+	for _, p := range sorted {
+		dir := filepath.Dir(p)
+		name := filepath.Base(p)
+
+		newName := name
+		if opts.Pattern != nil {
+			newName = opts.Pattern.ReplaceAllString(newName, opts.Replacement)
+		}
+		for _, t := range opts.Transforms {
+			newName = t(newName)
+		}
+		if newName == "" {
+			return nil, fmt.Errorf("rename of %q would produce an empty name", p)
+		}
+
+		newPath := filepath.Join(dir, newName)
+		if existing, ok := destOf[newPath]; ok && existing != p {
+			return nil, fmt.Errorf("rename collision: %q and %q both map to %q", existing, p, newPath)
+		}
+		destOf[newPath] = p
+		renames = append(renames, Rename{Old: p, New: newPath})
+	}
+	return renames, nil
+}
+
+// Apply executes renames on disk. It first checks that every destination is
+// either free or itself one of the sources being renamed away, so the whole
+// batch aborts before any file is touched rather than partially applying.
+// Renames that form a chain (A -> B where B is itself some other entry's
+// source) are ordered so a destination is always vacated before anything
+// moves into it; a chain that loops back on itself (e.g. a.txt/b.txt
+// swapping names) is broken with a temporary name so no file is ever
+// overwritten before its own content has been moved onward.
+func Apply(renames []Rename) error {
+	srcSet := make(map[string]bool, len(renames))
+	byOld := make(map[string]Rename, len(renames))
+	for _, r := range renames {
+		srcSet[r.Old] = true
+		byOld[r.Old] = r
+	}
+	for _, r := range renames {
+		if r.Old == r.New {
+			continue
+		}
+		if _, err := os.Lstat(r.New); err == nil && !srcSet[r.New] {
+			return fmt.Errorf("refusing to rename %q -> %q: destination already exists", r.Old, r.New)
+		}
+	}
+
+	done := make(map[string]bool, len(renames))
+	for _, r := range renames {
+		if r.Old == r.New || done[r.Old] {
+			continue
+		}
+		if err := applyChain(r, byOld, done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyChain renames start and, transitively, every rename blocking it
+// (i.e. whose source is start's destination), in the order required so no
+// destination is overwritten before its own contents have been moved
+// onward. If the chain loops back on a rename already in it, the loop is
+// broken by redirecting the first rename through a temporary name.
+func applyChain(start Rename, byOld map[string]Rename, done map[string]bool) error {
+	var chain []Rename
+	seen := make(map[string]bool)
+
+	cur := start
+	for {
+		if cur.Old == cur.New || done[cur.Old] {
+			break
+		}
+		chain = append(chain, cur)
+		seen[cur.Old] = true
+
+		next, ok := byOld[cur.New]
+		if !ok || next.Old == next.New || done[next.Old] {
+			break
+		}
+		if seen[next.Old] {
+			return applyCycle(chain, done)
+		}
+		cur = next
+	}
+
+	// No cycle: apply in reverse so each destination is vacated by the
+	// next rename in the chain before anything moves into it.
+	for i := len(chain) - 1; i >= 0; i-- {
+		r := chain[i]
+		if err := os.Rename(r.Old, r.New); err != nil {
+			return fmt.Errorf("renaming %q -> %q: %w", r.Old, r.New, err)
+		}
+		done[r.Old] = true
+	}
+	return nil
+}
+
+// applyCycle executes a rename chain that loops back on itself. chain[0] is
+// moved aside to a temporary name first (vacating its source, which is the
+// destination of the chain's last rename), the rest of the chain runs in reverse so
+// each destination is vacated before use, and finally the temp file is
+// renamed into chain[0]'s destination.
+func applyCycle(chain []Rename, done map[string]bool) error {
+	first := chain[0]
+
+	tmp := first.Old + ".cmd-img-tmp"
+	for {
+		if _, err := os.Lstat(tmp); err != nil {
+			break
+		}
+		tmp += "~"
+	}
+
+	if err := os.Rename(first.Old, tmp); err != nil {
+		return fmt.Errorf("renaming %q -> %q: %w", first.Old, tmp, err)
+	}
+	for i := len(chain) - 1; i >= 1; i-- {
+		r := chain[i]
+		if err := os.Rename(r.Old, r.New); err != nil {
+			return fmt.Errorf("renaming %q -> %q: %w", r.Old, r.New, err)
+		}
+		done[r.Old] = true
+	}
+	if err := os.Rename(tmp, first.New); err != nil {
+		return fmt.Errorf("renaming %q -> %q: %w", tmp, first.New, err)
+	}
+	done[first.Old] = true
+	return nil
+}
+
+// CollectFiles expands roots into a flat list of paths to rename. A root
+// that is a regular file is included as-is. A root that is a directory is
+// expanded to its entries (recursing when recursive is true); directories
+// are only included themselves when includeDirs is true. exclude entries
+// are shell glob patterns matched against each basename.
+func CollectFiles(roots []string, recursive, includeDirs bool, exclude []string) ([]string, error) {
+	var out []string
+	excluded := func(name string) (bool, error) {
+		for _, pat := range exclude {
+			ok, err := filepath.Match(pat, name)
+			if err != nil {
+				return false, fmt.Errorf("invalid --exclude pattern %q: %w", pat, err)
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	for _, root := range roots {
+		fi, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", root, err)
+		}
+		if !fi.IsDir() {
+			out = append(out, root)
+			continue
+		}
+
+		walk := func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root {
+				return nil
+			}
+			skip, mErr := excluded(d.Name())
+			if mErr != nil {
+				return mErr
+			}
+			if skip {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if includeDirs {
+					out = append(out, path)
+				}
+				if !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			out = append(out, path)
+			return nil
+		}
+		if err := filepath.WalkDir(root, walk); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
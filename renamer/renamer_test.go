@@ -0,0 +1,155 @@
+package renamer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+	names := []string{"img10.png", "img2.png", "img1.png", "img02.png"}
+	sort.Slice(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+
+	want := []string{"img1.png", "img2.png", "img02.png", "img10.png"}
+	if len(names) != len(want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got %v, want %v", names, want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestApplySimpleRename(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	writeFile(t, a, "A")
+
+	if err := Apply([]Rename{{Old: a, New: b}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(a); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be gone, got err=%v", a, err)
+	}
+	if got := readFile(t, b); got != "A" {
+		t.Fatalf("b.txt = %q, want %q", got, "A")
+	}
+}
+
+// TestApplySwapCycle guards against the bug where Apply executed a 2-cycle
+// (a.txt<->b.txt) in source order, letting the first os.Rename silently
+// overwrite the second file's original content before it could be moved.
+func TestApplySwapCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	writeFile(t, a, "A")
+	writeFile(t, b, "B")
+
+	err := Apply([]Rename{
+		{Old: a, New: b},
+		{Old: b, New: a},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, a); got != "B" {
+		t.Errorf("a.txt = %q, want %q", got, "B")
+	}
+	if got := readFile(t, b); got != "A" {
+		t.Errorf("b.txt = %q, want %q", got, "A")
+	}
+}
+
+// TestApplyThreeWayCycle exercises a longer rotation (a->b->c->a) to make
+// sure cycle-breaking generalizes past the two-element case.
+func TestApplyThreeWayCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	c := filepath.Join(dir, "c.txt")
+	writeFile(t, a, "A")
+	writeFile(t, b, "B")
+	writeFile(t, c, "C")
+
+	err := Apply([]Rename{
+		{Old: a, New: b},
+		{Old: b, New: c},
+		{Old: c, New: a},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, a); got != "C" {
+		t.Errorf("a.txt = %q, want %q", got, "C")
+	}
+	if got := readFile(t, b); got != "A" {
+		t.Errorf("b.txt = %q, want %q", got, "A")
+	}
+	if got := readFile(t, c); got != "B" {
+		t.Errorf("c.txt = %q, want %q", got, "B")
+	}
+}
+
+func TestApplyChain(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	c := filepath.Join(dir, "c.txt")
+	writeFile(t, a, "A")
+	writeFile(t, b, "B")
+
+	// a -> b -> c is a chain, not a cycle: b must move to c before a can
+	// take b's spot, so the chain has to apply in reverse order.
+	err := Apply([]Rename{
+		{Old: a, New: b},
+		{Old: b, New: c},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, b); got != "A" {
+		t.Errorf("b.txt = %q, want %q", got, "A")
+	}
+	if got := readFile(t, c); got != "B" {
+		t.Errorf("c.txt = %q, want %q", got, "B")
+	}
+}
+
+func TestApplyRefusesExistingDestination(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	writeFile(t, a, "A")
+	writeFile(t, b, "B")
+
+	err := Apply([]Rename{{Old: a, New: b}})
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if got := readFile(t, a); got != "A" {
+		t.Fatalf("a.txt was touched despite the refusal: %q", got)
+	}
+	if got := readFile(t, b); got != "B" {
+		t.Fatalf("b.txt was touched despite the refusal: %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+package cmdimg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Shell is the single choke point for side effects: running external
+// commands and mutating the filesystem. It supports previewing actions via
+// DryRun and echoing them via Trace, mirroring the -n/-x flags of cmd/go's
+// internal shell (see cmd/go/internal/work/shell.go upstream).
+type Shell struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	DryRun bool
+	Trace  bool
+}
+
+// NewShell constructs a Shell that streams command output to stdout/stderr.
+func NewShell(stdout, stderr io.Writer, dryRun, trace bool) *Shell {
+	return &Shell{Stdout: stdout, Stderr: stderr, DryRun: dryRun, Trace: trace}
+}
+
+func (s *Shell) traceln(format string, args ...any) {
+	if s.Trace {
+		fmt.Fprintf(s.Stderr, "+ "+format+"\n", args...)
+	}
+}
+
+// Do traces description and, unless DryRun is set, runs fn. description is
+// a human-readable rendering of the action, used for both the trace line
+// and the dry-run preview, so every mutation funnels through here.
+func (s *Shell) Do(description string, fn func() error) error {
+	s.traceln("%s", description)
+	if s.DryRun {
+		fmt.Fprintln(s.Stdout, description)
+		return nil
+	}
+	return fn()
+}
+
+// Run executes name with args, streaming its stdout/stderr through the
+// Shell's writers.
+func (s *Shell) Run(ctx context.Context, name string, args ...string) error {
+	return s.Do(quoteCmd(name, args), func() error {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stdout = s.Stdout
+		cmd.Stderr = s.Stderr
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	})
+}
+
+// RunOut runs name with args and returns its captured stdout. It always
+// executes, even under DryRun, since callers need the result (e.g.
+// `identify`) to decide what to do next; it never mutates anything.
+func (s *Shell) RunOut(ctx context.Context, name string, args ...string) ([]byte, error) {
+	s.traceln("%s", quoteCmd(name, args))
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = s.Stderr
+	return cmd.Output()
+}
+
+// Copy copies src to dst.
+func (s *Shell) Copy(src, dst string) error {
+	return s.Do(fmt.Sprintf("cp %s %s", src, dst), func() error {
+		return copyFile(src, dst)
+	})
+}
+
+// Remove removes path.
+func (s *Shell) Remove(path string) error {
+	return s.Do(fmt.Sprintf("rm %s", path), func() error {
+		return os.Remove(path)
+	})
+}
+
+// Mkdir creates path and any missing parents.
+func (s *Shell) Mkdir(path string) error {
+	return s.Do(fmt.Sprintf("mkdir -p %s", path), func() error {
+		return os.MkdirAll(path, 0o755)
+	})
+}
+
+func quoteCmd(name string, args []string) string {
+	out := name
+	for _, a := range args {
+		out += " " + a
+	}
+	return out
+}
+
+// Runner bundles the Shell used for side effects and is threaded through
+// every subcommand's RunE, so -n/--dry-run and -x/--trace apply uniformly.
+type Runner struct {
+	Shell *Shell
+}
@@ -0,0 +1,117 @@
+package cmdimg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShellDoDryRunSkipsFn(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	s := NewShell(&stdout, &stderr, true, false)
+
+	called := false
+	err := s.Do("rm foo", func() error {
+		called = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if called {
+		t.Fatal("fn should not run under DryRun")
+	}
+	if got := stdout.String(); got != "rm foo\n" {
+		t.Fatalf("stdout = %q, want the previewed description", got)
+	}
+}
+
+func TestShellDoRunsFnAndReturnsItsError(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	s := NewShell(&stdout, &stderr, false, false)
+
+	called := false
+	err := s.Do("touch foo", func() error {
+		called = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Fatal("fn should run when DryRun is false")
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("stdout = %q, want nothing written on a real run", stdout.String())
+	}
+}
+
+func TestShellDoTraces(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	s := NewShell(&stdout, &stderr, false, true)
+
+	if err := s.Do("touch foo", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got := stderr.String(); got != "+ touch foo\n" {
+		t.Fatalf("stderr = %q, want the trace line", got)
+	}
+}
+
+func TestShellCopy(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err := os.WriteFile(src, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	s := NewShell(&stdout, &stderr, false, false)
+	if err := s.Copy(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "data" {
+		t.Fatalf("dst = %q, want %q", got, "data")
+	}
+}
+
+func TestShellCopyDryRunDoesNotTouchDisk(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err := os.WriteFile(src, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	s := NewShell(&stdout, &stderr, true, false)
+	if err := s.Copy(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("dst should not exist under DryRun, stat err=%v", err)
+	}
+}
+
+func TestShellRemoveDryRunDoesNotTouchDisk(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	s := NewShell(&stdout, &stderr, true, false)
+	if err := s.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("file should still exist under DryRun: %v", err)
+	}
+}